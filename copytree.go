@@ -0,0 +1,296 @@
+package shutil
+
+import (
+  "fmt"
+  "io/ioutil"
+  "os"
+  "path/filepath"
+)
+
+
+// SymlinkAction tells CopyTree how to handle a symbolic link found in src.
+type SymlinkAction int
+
+const (
+  // Deep follows the symlink and copies whatever it points to (file or
+  // directory) in its place.
+  Deep SymlinkAction = iota
+  // Shallow recreates the symlink itself in dst. This is the default.
+  Shallow
+  // SkipSymlink leaves the symlink out of dst entirely.
+  SkipSymlink
+)
+
+// DirExistsAction tells CopyTree what to do when dst already exists as a
+// directory.
+type DirExistsAction int
+
+const (
+  // Merge copies src's contents into the existing dst directory. This is
+  // the default.
+  Merge DirExistsAction = iota
+  // Replace removes the existing dst directory before copying.
+  Replace
+  // AbortErr causes CopyTree to fail with a DirExistsError.
+  AbortErr
+)
+
+// DirExistsError is returned by CopyTree (via Options.OnError, unless it
+// suppresses it) when dst already exists and OnDirExists returns AbortErr.
+type DirExistsError struct {
+  Dst string
+}
+
+func (e DirExistsError) Error() string {
+  return fmt.Sprintf("%s already exists", e.Dst)
+}
+
+// Options configures the behavior of CopyTree.
+type Options struct {
+  // OnSymlink decides how a symlink in src is handled. If nil, symlinks
+  // are recreated as symlinks in dst (Shallow).
+  OnSymlink func(src string) SymlinkAction
+
+  // OnDirExists decides what to do when dst already exists as a
+  // directory. If nil, CopyTree merges src into it (Merge).
+  OnDirExists func(src, dst string) DirExistsAction
+
+  // OnError lets callers suppress or remap an error encountered while
+  // walking or copying a file or directory. Returning nil continues the
+  // walk; returning a non-nil error (the original or a replacement)
+  // aborts it. If nil, every error aborts the walk immediately.
+  OnError func(src, dst string, err error) error
+
+  // Skip lets callers exclude individual files, directories or symlinks
+  // from the copy. fi is the result of os.Lstat(src). If nil, nothing
+  // is skipped.
+  Skip func(fi os.FileInfo, src, dst string) (bool, error)
+
+  // Ignore is shutil.copytree-style: given a directory and the names of
+  // its entries, it returns the subset of names to leave out of dst.
+  Ignore func(dir string, names []string) []string
+
+  // PreserveTimes sets dst's mtime and atime to src's mtime for every
+  // file and directory CopyTree creates; src's atime is not copied by
+  // this step.
+  PreserveTimes bool
+
+  // Parallelism is the number of worker goroutines CopyTreeParallel uses
+  // to copy regular files. It has no effect on CopyTree. If <= 0, it
+  // defaults to runtime.GOMAXPROCS(0).
+  Parallelism int
+
+  // fastPath is lazily initialized by CopyTree/CopyTreeParallel and
+  // shared by every file they copy, so a FICLONE/copy_file_range
+  // failure on one file disables that path for the rest of the walk
+  // instead of being retried per file.
+  fastPath *fastPathState
+}
+
+func copyTreeError(opts *Options, src, dst string, err error) error {
+  if err == nil {
+    return nil
+  }
+  if opts.OnError != nil {
+    return opts.OnError(src, dst, err)
+  }
+  return err
+}
+
+func preserveTimes(src, dst string, srcStat os.FileInfo) error {
+  mtime := srcStat.ModTime()
+  return os.Chtimes(dst, mtime, mtime)
+}
+
+// preserveSymlinkTimes is preserveTimes for a symlink dst: os.Chtimes
+// follows symlinks, which would either stamp a dangling symlink's
+// nonexistent target (failing outright) or some unrelated file the
+// symlink happens to point at, so this goes through the NOFOLLOW-aware
+// lchtimes instead.
+func preserveSymlinkTimes(src, dst string, srcStat os.FileInfo) error {
+  mtime := srcStat.ModTime()
+  return lchtimes(dst, mtime, mtime)
+}
+
+// CopyTree recursively copies a directory tree rooted at src to dst,
+// Python shutil.copytree style. Regular files are copied with Copy2, so
+// timestamps, ownership and extended attributes are preserved like
+// "cp -a". src may also be a single file, in which case CopyTree behaves
+// like Copy2.
+//
+// opts may be nil, in which case CopyTree merges into an existing dst
+// directory, copies symlinks as symlinks (rather than dereferencing
+// them, see SymlinkAction), and aborts on the first error.
+func CopyTree(src, dst string, opts *Options) error {
+  if opts == nil {
+    opts = &Options{}
+  }
+  if opts.fastPath == nil {
+    opts.fastPath = &fastPathState{}
+  }
+
+  srcStat, err := os.Lstat(src)
+  if err != nil {
+    return copyTreeError(opts, src, dst, err)
+  }
+
+  if opts.Skip != nil {
+    skip, err := opts.Skip(srcStat, src, dst)
+    if err != nil {
+      return copyTreeError(opts, src, dst, err)
+    }
+    if skip {
+      return nil
+    }
+  }
+
+  if IsSymlink(srcStat) {
+    return copyTreeSymlink(src, dst, srcStat, opts)
+  }
+
+  if srcStat.IsDir() {
+    return copyTreeDir(src, dst, srcStat, opts)
+  }
+
+  return copyTreeFile(src, dst, srcStat, opts)
+}
+
+func copyTreeSymlink(src, dst string, srcStat os.FileInfo, opts *Options) error {
+  action := Shallow
+  if opts.OnSymlink != nil {
+    action = opts.OnSymlink(src)
+  }
+
+  switch action {
+  case SkipSymlink:
+    return nil
+
+  case Deep:
+    realSrc, err := filepath.EvalSymlinks(src)
+    if err != nil {
+      return copyTreeError(opts, src, dst, err)
+    }
+    realStat, err := os.Stat(realSrc)
+    if err != nil {
+      return copyTreeError(opts, src, dst, err)
+    }
+    if realStat.IsDir() {
+      return copyTreeDir(realSrc, dst, realStat, opts)
+    }
+    return copyTreeFile(realSrc, dst, realStat, opts)
+
+  default: // Shallow
+    target, err := os.Readlink(src)
+    if err != nil {
+      return copyTreeError(opts, src, dst, err)
+    }
+    if err := os.Symlink(target, dst); err != nil {
+      return copyTreeError(opts, src, dst, err)
+    }
+    if opts.PreserveTimes {
+      return copyTreeError(opts, src, dst, preserveSymlinkTimes(src, dst, srcStat))
+    }
+    return nil
+  }
+}
+
+// prepareDstDir creates (or merges/replaces, per opts.OnDirExists) dst
+// as a directory matching src, and returns the src entries that should
+// be copied into it (after opts.Ignore has had a chance to trim the
+// list). Shared by the serial walker (copyTreeDir) and the parallel
+// walker (walkTreeDir).
+func prepareDstDir(src, dst string, srcStat os.FileInfo, opts *Options) ([]os.FileInfo, error) {
+  dstStat, err := os.Lstat(dst)
+  switch {
+  case err == nil && dstStat.IsDir():
+    action := Merge
+    if opts.OnDirExists != nil {
+      action = opts.OnDirExists(src, dst)
+    }
+    switch action {
+    case AbortErr:
+      return nil, &DirExistsError{dst}
+    case Replace:
+      if err := os.RemoveAll(dst); err != nil {
+        return nil, err
+      }
+      if err := os.MkdirAll(dst, srcStat.Mode().Perm()); err != nil {
+        return nil, err
+      }
+    } // Merge falls through and copies into the existing directory.
+
+  case err == nil:
+    return nil, fmt.Errorf("%s already exists and is not a directory", dst)
+
+  case os.IsNotExist(err):
+    if err := os.MkdirAll(dst, srcStat.Mode().Perm()); err != nil {
+      return nil, err
+    }
+
+  default:
+    return nil, err
+  }
+
+  entries, err := ioutil.ReadDir(src)
+  if err != nil {
+    return nil, err
+  }
+
+  if opts.Ignore == nil {
+    return entries, nil
+  }
+
+  names := make([]string, len(entries))
+  for i, e := range entries {
+    names[i] = e.Name()
+  }
+  ignoreNames := opts.Ignore(src, names)
+  ignored := make(map[string]bool, len(ignoreNames))
+  for _, n := range ignoreNames {
+    ignored[n] = true
+  }
+  kept := entries[:0]
+  for _, e := range entries {
+    if !ignored[e.Name()] {
+      kept = append(kept, e)
+    }
+  }
+  return kept, nil
+}
+
+func copyTreeDir(src, dst string, srcStat os.FileInfo, opts *Options) error {
+  entries, err := prepareDstDir(src, dst, srcStat, opts)
+  if err != nil {
+    return copyTreeError(opts, src, dst, err)
+  }
+
+  for _, e := range entries {
+    childSrc := filepath.Join(src, e.Name())
+    childDst := filepath.Join(dst, e.Name())
+    if err := CopyTree(childSrc, childDst, opts); err != nil {
+      return err
+    }
+  }
+
+  if opts.PreserveTimes {
+    if err := preserveTimes(src, dst, srcStat); err != nil {
+      return copyTreeError(opts, src, dst, err)
+    }
+  }
+
+  return nil
+}
+
+func copyTreeFile(src, dst string, srcStat os.FileInfo, opts *Options) error {
+  if _, err := copy2(src, dst, true, opts.fastPath); err != nil {
+    return copyTreeError(opts, src, dst, err)
+  }
+
+  if opts.PreserveTimes {
+    if err := preserveTimes(src, dst, srcStat); err != nil {
+      return copyTreeError(opts, src, dst, err)
+    }
+  }
+
+  return nil
+}
@@ -0,0 +1,187 @@
+package shutil
+
+import (
+  "context"
+  "os"
+  "path/filepath"
+  "runtime"
+  "sync"
+)
+
+// copyJob is one regular file waiting to be copied by a worker.
+type copyJob struct {
+  src, dst string
+  fi       os.FileInfo
+}
+
+// CopyTreeParallel is CopyTree for large trees: a single walker goroutine
+// creates directories and symlinks synchronously, the same invariants
+// CopyTree gives you (a file's parent directory exists and is writable
+// before the file is copied into it), while it hands regular-file copies
+// off to a pool of opts.Parallelism worker goroutines.
+//
+// Errors are funneled through opts.OnError exactly as in CopyTree. The
+// first error neither the walker nor a worker can suppress cancels
+// outstanding work and is returned; the walker and workers may have
+// already made partial progress on the rest of the tree.
+//
+// opts may be nil, with the same defaults as CopyTree, plus
+// opts.Parallelism defaulting to runtime.GOMAXPROCS(0).
+func CopyTreeParallel(src, dst string, opts *Options) error {
+  if opts == nil {
+    opts = &Options{}
+  }
+  if opts.fastPath == nil {
+    opts.fastPath = &fastPathState{}
+  }
+
+  parallelism := opts.Parallelism
+  if parallelism <= 0 {
+    parallelism = runtime.GOMAXPROCS(0)
+  }
+
+  ctx, cancel := context.WithCancel(context.Background())
+  defer cancel()
+
+  jobs := make(chan copyJob, parallelism)
+  var wg sync.WaitGroup
+  var once sync.Once
+  var firstErr error
+  fail := func(err error) {
+    once.Do(func() {
+      firstErr = err
+      cancel()
+    })
+  }
+
+  wg.Add(parallelism)
+  for i := 0; i < parallelism; i++ {
+    go func() {
+      defer wg.Done()
+      for job := range jobs {
+        if ctx.Err() != nil {
+          continue
+        }
+        if err := copyTreeFile(job.src, job.dst, job.fi, opts); err != nil {
+          fail(err)
+        }
+      }
+    }()
+  }
+
+  if walkErr := walkTreeParallel(ctx, src, dst, opts, jobs); walkErr != nil {
+    fail(walkErr)
+  }
+  close(jobs)
+  wg.Wait()
+
+  return firstErr
+}
+
+func walkTreeParallel(ctx context.Context, src, dst string, opts *Options, jobs chan<- copyJob) error {
+  select {
+  case <-ctx.Done():
+    return nil
+  default:
+  }
+
+  srcStat, err := os.Lstat(src)
+  if err != nil {
+    return copyTreeError(opts, src, dst, err)
+  }
+
+  if opts.Skip != nil {
+    skip, err := opts.Skip(srcStat, src, dst)
+    if err != nil {
+      return copyTreeError(opts, src, dst, err)
+    }
+    if skip {
+      return nil
+    }
+  }
+
+  if IsSymlink(srcStat) {
+    return walkTreeSymlink(ctx, src, dst, srcStat, opts, jobs)
+  }
+
+  if srcStat.IsDir() {
+    return walkTreeDir(ctx, src, dst, srcStat, opts, jobs)
+  }
+
+  select {
+  case jobs <- copyJob{src, dst, srcStat}:
+  case <-ctx.Done():
+  }
+  return nil
+}
+
+func walkTreeSymlink(ctx context.Context, src, dst string, srcStat os.FileInfo, opts *Options, jobs chan<- copyJob) error {
+  action := Shallow
+  if opts.OnSymlink != nil {
+    action = opts.OnSymlink(src)
+  }
+
+  switch action {
+  case SkipSymlink:
+    return nil
+
+  case Deep:
+    realSrc, err := filepath.EvalSymlinks(src)
+    if err != nil {
+      return copyTreeError(opts, src, dst, err)
+    }
+    realStat, err := os.Stat(realSrc)
+    if err != nil {
+      return copyTreeError(opts, src, dst, err)
+    }
+    if realStat.IsDir() {
+      return walkTreeDir(ctx, realSrc, dst, realStat, opts, jobs)
+    }
+    select {
+    case jobs <- copyJob{realSrc, dst, realStat}:
+    case <-ctx.Done():
+    }
+    return nil
+
+  default: // Shallow
+    target, err := os.Readlink(src)
+    if err != nil {
+      return copyTreeError(opts, src, dst, err)
+    }
+    if err := os.Symlink(target, dst); err != nil {
+      return copyTreeError(opts, src, dst, err)
+    }
+    if opts.PreserveTimes {
+      return copyTreeError(opts, src, dst, preserveSymlinkTimes(src, dst, srcStat))
+    }
+    return nil
+  }
+}
+
+func walkTreeDir(ctx context.Context, src, dst string, srcStat os.FileInfo, opts *Options, jobs chan<- copyJob) error {
+  entries, err := prepareDstDir(src, dst, srcStat, opts)
+  if err != nil {
+    return copyTreeError(opts, src, dst, err)
+  }
+
+  for _, e := range entries {
+    childSrc := filepath.Join(src, e.Name())
+    childDst := filepath.Join(dst, e.Name())
+    if err := walkTreeParallel(ctx, childSrc, childDst, opts, jobs); err != nil {
+      return err
+    }
+  }
+
+  if opts.PreserveTimes {
+    // Best-effort: worker goroutines may still be copying files into
+    // this directory when we restore its times here, and their writes
+    // will bump mtime again afterwards. A byte-for-byte match would
+    // need a per-directory barrier, which defeats the point of copying
+    // in parallel.
+    if err := preserveTimes(src, dst, srcStat); err != nil {
+      return copyTreeError(opts, src, dst, err)
+    }
+  }
+
+  return nil
+}
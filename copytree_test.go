@@ -0,0 +1,433 @@
+package shutil
+
+import (
+  "errors"
+  "os"
+  "path/filepath"
+  "testing"
+  "time"
+)
+
+func mustWriteFile(t *testing.T, path, contents string) {
+  t.Helper()
+  if err := os.WriteFile(path, []byte(contents), 0644); err != nil {
+    t.Fatalf("WriteFile(%s): %v", path, err)
+  }
+}
+
+func mustReadFile(t *testing.T, path string) string {
+  t.Helper()
+  b, err := os.ReadFile(path)
+  if err != nil {
+    t.Fatalf("ReadFile(%s): %v", path, err)
+  }
+  return string(b)
+}
+
+func TestCopyTree_Basic(t *testing.T) {
+  root := t.TempDir()
+  src := filepath.Join(root, "src")
+  dst := filepath.Join(root, "dst")
+
+  if err := os.MkdirAll(filepath.Join(src, "sub"), 0755); err != nil {
+    t.Fatal(err)
+  }
+  mustWriteFile(t, filepath.Join(src, "a.txt"), "a")
+  mustWriteFile(t, filepath.Join(src, "sub", "b.txt"), "b")
+
+  if err := CopyTree(src, dst, nil); err != nil {
+    t.Fatalf("CopyTree: %v", err)
+  }
+
+  if got := mustReadFile(t, filepath.Join(dst, "a.txt")); got != "a" {
+    t.Errorf("a.txt = %q, want %q", got, "a")
+  }
+  if got := mustReadFile(t, filepath.Join(dst, "sub", "b.txt")); got != "b" {
+    t.Errorf("sub/b.txt = %q, want %q", got, "b")
+  }
+}
+
+func TestCopyTree_OnSymlink(t *testing.T) {
+  t.Run("default is shallow", func(t *testing.T) {
+    root := t.TempDir()
+    src := filepath.Join(root, "src")
+    dst := filepath.Join(root, "dst")
+    if err := os.MkdirAll(src, 0755); err != nil {
+      t.Fatal(err)
+    }
+    mustWriteFile(t, filepath.Join(src, "target.txt"), "hi")
+    if err := os.Symlink("target.txt", filepath.Join(src, "link.txt")); err != nil {
+      t.Fatal(err)
+    }
+
+    if err := CopyTree(src, dst, nil); err != nil {
+      t.Fatalf("CopyTree: %v", err)
+    }
+
+    fi, err := os.Lstat(filepath.Join(dst, "link.txt"))
+    if err != nil {
+      t.Fatalf("Lstat: %v", err)
+    }
+    if !IsSymlink(fi) {
+      t.Fatalf("link.txt should still be a symlink in dst")
+    }
+    target, err := os.Readlink(filepath.Join(dst, "link.txt"))
+    if err != nil {
+      t.Fatalf("Readlink: %v", err)
+    }
+    if target != "target.txt" {
+      t.Errorf("symlink target = %q, want %q", target, "target.txt")
+    }
+  })
+
+  t.Run("deep follows and copies content", func(t *testing.T) {
+    root := t.TempDir()
+    src := filepath.Join(root, "src")
+    dst := filepath.Join(root, "dst")
+    if err := os.MkdirAll(src, 0755); err != nil {
+      t.Fatal(err)
+    }
+    mustWriteFile(t, filepath.Join(src, "target.txt"), "hi")
+    if err := os.Symlink("target.txt", filepath.Join(src, "link.txt")); err != nil {
+      t.Fatal(err)
+    }
+
+    opts := &Options{
+      OnSymlink: func(src string) SymlinkAction { return Deep },
+    }
+    if err := CopyTree(src, dst, opts); err != nil {
+      t.Fatalf("CopyTree: %v", err)
+    }
+
+    fi, err := os.Lstat(filepath.Join(dst, "link.txt"))
+    if err != nil {
+      t.Fatalf("Lstat: %v", err)
+    }
+    if IsSymlink(fi) {
+      t.Fatalf("link.txt should have been dereferenced into a regular file")
+    }
+    if got := mustReadFile(t, filepath.Join(dst, "link.txt")); got != "hi" {
+      t.Errorf("link.txt content = %q, want %q", got, "hi")
+    }
+  })
+
+  t.Run("skip leaves it out", func(t *testing.T) {
+    root := t.TempDir()
+    src := filepath.Join(root, "src")
+    dst := filepath.Join(root, "dst")
+    if err := os.MkdirAll(src, 0755); err != nil {
+      t.Fatal(err)
+    }
+    mustWriteFile(t, filepath.Join(src, "target.txt"), "hi")
+    if err := os.Symlink("target.txt", filepath.Join(src, "link.txt")); err != nil {
+      t.Fatal(err)
+    }
+
+    opts := &Options{
+      OnSymlink: func(src string) SymlinkAction { return SkipSymlink },
+    }
+    if err := CopyTree(src, dst, opts); err != nil {
+      t.Fatalf("CopyTree: %v", err)
+    }
+
+    if _, err := os.Lstat(filepath.Join(dst, "link.txt")); !os.IsNotExist(err) {
+      t.Fatalf("link.txt should not exist in dst, got err=%v", err)
+    }
+  })
+
+  t.Run("dangling symlink, default shallow, still copies", func(t *testing.T) {
+    root := t.TempDir()
+    src := filepath.Join(root, "src")
+    dst := filepath.Join(root, "dst")
+    if err := os.MkdirAll(src, 0755); err != nil {
+      t.Fatal(err)
+    }
+    if err := os.Symlink("does-not-exist", filepath.Join(src, "dangling")); err != nil {
+      t.Fatal(err)
+    }
+
+    if err := CopyTree(src, dst, nil); err != nil {
+      t.Fatalf("CopyTree: %v", err)
+    }
+
+    fi, err := os.Lstat(filepath.Join(dst, "dangling"))
+    if err != nil {
+      t.Fatalf("Lstat: %v", err)
+    }
+    if !IsSymlink(fi) {
+      t.Fatalf("dangling should have been recreated as a symlink")
+    }
+  })
+
+  t.Run("dangling symlink, shallow, PreserveTimes doesn't follow it", func(t *testing.T) {
+    root := t.TempDir()
+    src := filepath.Join(root, "src")
+    dst := filepath.Join(root, "dst")
+    if err := os.MkdirAll(src, 0755); err != nil {
+      t.Fatal(err)
+    }
+    if err := os.Symlink("does-not-exist", filepath.Join(src, "dangling")); err != nil {
+      t.Fatal(err)
+    }
+
+    if err := CopyTree(src, dst, &Options{PreserveTimes: true}); err != nil {
+      t.Fatalf("CopyTree: %v", err)
+    }
+
+    fi, err := os.Lstat(filepath.Join(dst, "dangling"))
+    if err != nil {
+      t.Fatalf("Lstat: %v", err)
+    }
+    if !IsSymlink(fi) {
+      t.Fatalf("dangling should have been recreated as a symlink")
+    }
+  })
+
+  t.Run("dangling symlink, deep, errors but OnError can suppress", func(t *testing.T) {
+    root := t.TempDir()
+    src := filepath.Join(root, "src")
+    dst := filepath.Join(root, "dst")
+    if err := os.MkdirAll(src, 0755); err != nil {
+      t.Fatal(err)
+    }
+    if err := os.Symlink("does-not-exist", filepath.Join(src, "dangling")); err != nil {
+      t.Fatal(err)
+    }
+
+    deepOpts := &Options{OnSymlink: func(src string) SymlinkAction { return Deep }}
+    if err := CopyTree(src, dst, deepOpts); err == nil {
+      t.Fatalf("expected an error deep-copying a dangling symlink")
+    }
+
+    suppressed := &Options{
+      OnSymlink: func(src string) SymlinkAction { return Deep },
+      OnError: func(src, dst string, err error) error {
+        return nil
+      },
+    }
+    if err := CopyTree(src, dst, suppressed); err != nil {
+      t.Fatalf("CopyTree with suppressing OnError: %v", err)
+    }
+  })
+}
+
+func TestCopyTree_OnDirExists(t *testing.T) {
+  t.Run("merge is the default", func(t *testing.T) {
+    root := t.TempDir()
+    src := filepath.Join(root, "src")
+    dst := filepath.Join(root, "dst")
+    if err := os.MkdirAll(src, 0755); err != nil {
+      t.Fatal(err)
+    }
+    if err := os.MkdirAll(dst, 0755); err != nil {
+      t.Fatal(err)
+    }
+    mustWriteFile(t, filepath.Join(dst, "existing.txt"), "old")
+    mustWriteFile(t, filepath.Join(src, "new.txt"), "new")
+
+    if err := CopyTree(src, dst, nil); err != nil {
+      t.Fatalf("CopyTree: %v", err)
+    }
+
+    if got := mustReadFile(t, filepath.Join(dst, "existing.txt")); got != "old" {
+      t.Errorf("existing.txt was clobbered: %q", got)
+    }
+    if got := mustReadFile(t, filepath.Join(dst, "new.txt")); got != "new" {
+      t.Errorf("new.txt = %q, want %q", got, "new")
+    }
+  })
+
+  t.Run("replace wipes the existing directory first", func(t *testing.T) {
+    root := t.TempDir()
+    src := filepath.Join(root, "src")
+    dst := filepath.Join(root, "dst")
+    if err := os.MkdirAll(src, 0755); err != nil {
+      t.Fatal(err)
+    }
+    if err := os.MkdirAll(dst, 0755); err != nil {
+      t.Fatal(err)
+    }
+    mustWriteFile(t, filepath.Join(dst, "stale.txt"), "stale")
+    mustWriteFile(t, filepath.Join(src, "new.txt"), "new")
+
+    opts := &Options{
+      OnDirExists: func(src, dst string) DirExistsAction { return Replace },
+    }
+    if err := CopyTree(src, dst, opts); err != nil {
+      t.Fatalf("CopyTree: %v", err)
+    }
+
+    if _, err := os.Stat(filepath.Join(dst, "stale.txt")); !os.IsNotExist(err) {
+      t.Errorf("stale.txt should have been removed, err=%v", err)
+    }
+    if got := mustReadFile(t, filepath.Join(dst, "new.txt")); got != "new" {
+      t.Errorf("new.txt = %q, want %q", got, "new")
+    }
+  })
+
+  t.Run("abort returns a DirExistsError", func(t *testing.T) {
+    root := t.TempDir()
+    src := filepath.Join(root, "src")
+    dst := filepath.Join(root, "dst")
+    if err := os.MkdirAll(src, 0755); err != nil {
+      t.Fatal(err)
+    }
+    if err := os.MkdirAll(dst, 0755); err != nil {
+      t.Fatal(err)
+    }
+
+    opts := &Options{
+      OnDirExists: func(src, dst string) DirExistsAction { return AbortErr },
+    }
+    err := CopyTree(src, dst, opts)
+    if err == nil {
+      t.Fatalf("expected a DirExistsError")
+    }
+    var dirExists *DirExistsError
+    if !errors.As(err, &dirExists) {
+      t.Errorf("err = %v (%T), want *DirExistsError", err, err)
+    }
+  })
+}
+
+func TestCopyTree_OnError(t *testing.T) {
+  t.Run("suppress lets the walk continue", func(t *testing.T) {
+    root := t.TempDir()
+    src := filepath.Join(root, "src")
+    dst := filepath.Join(root, "dst")
+    if err := os.MkdirAll(src, 0755); err != nil {
+      t.Fatal(err)
+    }
+    mustWriteFile(t, filepath.Join(src, "ok.txt"), "ok")
+    // A dangling symlink makes Deep mode fail on that one entry only.
+    if err := os.Symlink("missing", filepath.Join(src, "broken")); err != nil {
+      t.Fatal(err)
+    }
+
+    var suppressedErrs []error
+    opts := &Options{
+      OnSymlink: func(src string) SymlinkAction { return Deep },
+      OnError: func(src, dst string, err error) error {
+        suppressedErrs = append(suppressedErrs, err)
+        return nil
+      },
+    }
+    if err := CopyTree(src, dst, opts); err != nil {
+      t.Fatalf("CopyTree: %v", err)
+    }
+    if len(suppressedErrs) != 1 {
+      t.Fatalf("expected exactly one suppressed error, got %d: %v", len(suppressedErrs), suppressedErrs)
+    }
+    if got := mustReadFile(t, filepath.Join(dst, "ok.txt")); got != "ok" {
+      t.Errorf("ok.txt = %q, want %q", got, "ok")
+    }
+  })
+
+  t.Run("remap replaces the error", func(t *testing.T) {
+    root := t.TempDir()
+    src := filepath.Join(root, "missing-src")
+    dst := filepath.Join(root, "dst")
+
+    sentinel := errors.New("remapped")
+    opts := &Options{
+      OnError: func(src, dst string, err error) error {
+        return sentinel
+      },
+    }
+    err := CopyTree(src, dst, opts)
+    if !errors.Is(err, sentinel) {
+      t.Errorf("err = %v, want %v", err, sentinel)
+    }
+  })
+}
+
+func TestCopyTree_Skip(t *testing.T) {
+  root := t.TempDir()
+  src := filepath.Join(root, "src")
+  dst := filepath.Join(root, "dst")
+  if err := os.MkdirAll(src, 0755); err != nil {
+    t.Fatal(err)
+  }
+  mustWriteFile(t, filepath.Join(src, "keep.txt"), "keep")
+  mustWriteFile(t, filepath.Join(src, "drop.txt"), "drop")
+
+  opts := &Options{
+    Skip: func(fi os.FileInfo, src, dst string) (bool, error) {
+      return filepath.Base(src) == "drop.txt", nil
+    },
+  }
+  if err := CopyTree(src, dst, opts); err != nil {
+    t.Fatalf("CopyTree: %v", err)
+  }
+
+  if _, err := os.Stat(filepath.Join(dst, "keep.txt")); err != nil {
+    t.Errorf("keep.txt should exist: %v", err)
+  }
+  if _, err := os.Stat(filepath.Join(dst, "drop.txt")); !os.IsNotExist(err) {
+    t.Errorf("drop.txt should have been skipped, err=%v", err)
+  }
+}
+
+func TestCopyTree_Ignore(t *testing.T) {
+  root := t.TempDir()
+  src := filepath.Join(root, "src")
+  dst := filepath.Join(root, "dst")
+  if err := os.MkdirAll(src, 0755); err != nil {
+    t.Fatal(err)
+  }
+  mustWriteFile(t, filepath.Join(src, "keep.txt"), "keep")
+  mustWriteFile(t, filepath.Join(src, "ignore.log"), "ignore")
+
+  opts := &Options{
+    Ignore: func(dir string, names []string) []string {
+      var ignored []string
+      for _, name := range names {
+        if filepath.Ext(name) == ".log" {
+          ignored = append(ignored, name)
+        }
+      }
+      return ignored
+    },
+  }
+  if err := CopyTree(src, dst, opts); err != nil {
+    t.Fatalf("CopyTree: %v", err)
+  }
+
+  if _, err := os.Stat(filepath.Join(dst, "keep.txt")); err != nil {
+    t.Errorf("keep.txt should exist: %v", err)
+  }
+  if _, err := os.Stat(filepath.Join(dst, "ignore.log")); !os.IsNotExist(err) {
+    t.Errorf("ignore.log should have been ignored, err=%v", err)
+  }
+}
+
+func TestCopyTree_PreserveTimes(t *testing.T) {
+  root := t.TempDir()
+  src := filepath.Join(root, "src")
+  dst := filepath.Join(root, "dst")
+  if err := os.MkdirAll(src, 0755); err != nil {
+    t.Fatal(err)
+  }
+  mustWriteFile(t, filepath.Join(src, "a.txt"), "a")
+
+  old := time.Now().Add(-48 * time.Hour).Truncate(time.Second)
+  if err := os.Chtimes(filepath.Join(src, "a.txt"), old, old); err != nil {
+    t.Fatal(err)
+  }
+  if err := os.Chtimes(src, old, old); err != nil {
+    t.Fatal(err)
+  }
+
+  opts := &Options{PreserveTimes: true}
+  if err := CopyTree(src, dst, opts); err != nil {
+    t.Fatalf("CopyTree: %v", err)
+  }
+
+  fi, err := os.Stat(filepath.Join(dst, "a.txt"))
+  if err != nil {
+    t.Fatalf("Stat: %v", err)
+  }
+  if !fi.ModTime().Equal(old) {
+    t.Errorf("a.txt ModTime = %v, want %v", fi.ModTime(), old)
+  }
+}
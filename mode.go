@@ -0,0 +1,173 @@
+package shutil
+
+import (
+  "fmt"
+  "os"
+  "path/filepath"
+  "syscall"
+)
+
+
+// Mode selects how CopyWithMode transfers a regular file's data, the
+// same distinction container graph drivers make between a full content
+// copy, a hardlink and a copy-on-write reflink.
+type Mode int
+
+const (
+  // Content copies the file's data, same as Copy.
+  Content Mode = iota
+  // Hardlink links dst to src's inode with os.Link, falling back to a
+  // Content copy if src and dst are on different filesystems (EXDEV).
+  Hardlink
+  // Reflink clones src's data into dst with FICLONE. Unlike Hardlink,
+  // Reflink never falls back: it returns a ReflinkUnsupportedError if
+  // the filesystem doesn't support copy-on-write clones, so callers can
+  // detect that up front.
+  Reflink
+)
+
+// ReflinkUnsupportedError is returned by CopyWithMode in Reflink mode
+// when the underlying filesystem (or platform) doesn't support
+// copy-on-write clones.
+type ReflinkUnsupportedError struct {
+  Err error
+}
+
+func (e *ReflinkUnsupportedError) Error() string {
+  return fmt.Sprintf("reflink not supported: %s", e.Err)
+}
+
+func (e *ReflinkUnsupportedError) Unwrap() error {
+  return e.Err
+}
+
+// CopyWithMode is Copy with control over how the file's data is
+// transferred. See Mode for the available strategies. The destination
+// may be a directory, as with Copy.
+func CopyWithMode(src, dst string, mode Mode, followSymlinks bool) (string, error) {
+  dstInfo, err := os.Stat(dst)
+
+  if err == nil && dstInfo.Mode().IsDir() {
+    dst = filepath.Join(dst, filepath.Base(src))
+  }
+
+  if err != nil && !os.IsNotExist(err) {
+    return dst, err
+  }
+
+  switch mode {
+  case Hardlink:
+    linked, err := copyHardlink(src, dst, followSymlinks)
+    if err != nil {
+      return dst, err
+    }
+    // A real hardlink shares src's inode, so its mode is already
+    // correct; CopyFile's EXDEV fallback below copies only the
+    // contents and still needs it.
+    if linked {
+      return dst, nil
+    }
+
+  case Reflink:
+    if err := copyReflink(src, dst); err != nil {
+      return dst, err
+    }
+
+  default:
+    if err := CopyFile(src, dst, followSymlinks); err != nil {
+      return dst, err
+    }
+  }
+
+  if err := CopyMode(src, dst, followSymlinks); err != nil {
+    return dst, err
+  }
+
+  return dst, nil
+}
+
+func isCrossDevice(err error) bool {
+  linkErr, ok := err.(*os.LinkError)
+  if !ok {
+    return false
+  }
+  errno, ok := linkErr.Err.(syscall.Errno)
+  return ok && errno == syscall.EXDEV
+}
+
+// copyHardlink links dst to src's inode. It reports linked=true only
+// when that actually happened (mode bits already correct, shared with
+// src); false means it fell back to a symlink or a content copy, either
+// of which may still need CopyMode applied by the caller.
+func copyHardlink(src, dst string, followSymlinks bool) (linked bool, err error) {
+  if samefile(src, dst) {
+    return false, &SameFileError{src, dst}
+  }
+
+  srcStat, err := os.Lstat(src)
+  if err != nil {
+    return false, err
+  }
+  if specialfile(srcStat) {
+    return false, &SpecialFileError{src, srcStat}
+  }
+
+  if !followSymlinks && IsSymlink(srcStat) {
+    target, err := os.Readlink(src)
+    if err != nil {
+      return false, err
+    }
+    return false, os.Symlink(target, dst)
+  }
+
+  if IsSymlink(srcStat) {
+    src, err = filepath.EvalSymlinks(src)
+    if err != nil {
+      return false, err
+    }
+  }
+
+  if err := os.Link(src, dst); err != nil {
+    if isCrossDevice(err) {
+      return false, CopyFile(src, dst, followSymlinks)
+    }
+    return false, err
+  }
+
+  return true, nil
+}
+
+func copyReflink(src, dst string) error {
+  if samefile(src, dst) {
+    return &SameFileError{src, dst}
+  }
+
+  srcStat, err := os.Lstat(src)
+  if err != nil {
+    return err
+  }
+  if specialfile(srcStat) {
+    return &SpecialFileError{src, srcStat}
+  }
+
+  if IsSymlink(srcStat) {
+    src, err = filepath.EvalSymlinks(src)
+    if err != nil {
+      return err
+    }
+  }
+
+  fsrc, err := os.Open(src)
+  if err != nil {
+    return err
+  }
+  defer fsrc.Close()
+
+  fdst, err := os.Create(dst)
+  if err != nil {
+    return err
+  }
+  defer fdst.Close()
+
+  return reflink(fdst, fsrc)
+}
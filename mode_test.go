@@ -0,0 +1,75 @@
+package shutil
+
+import (
+  "os"
+  "path/filepath"
+  "testing"
+)
+
+func TestCopyWithMode_Hardlink(t *testing.T) {
+  root := t.TempDir()
+  src := filepath.Join(root, "src.txt")
+  dst := filepath.Join(root, "dst.txt")
+  mustWriteFile(t, src, "hello world")
+  if err := os.Chmod(src, 0600); err != nil {
+    t.Fatal(err)
+  }
+
+  if _, err := CopyWithMode(src, dst, Hardlink, true); err != nil {
+    t.Fatalf("CopyWithMode: %v", err)
+  }
+
+  srcInfo, err := os.Stat(src)
+  if err != nil {
+    t.Fatal(err)
+  }
+  dstInfo, err := os.Stat(dst)
+  if err != nil {
+    t.Fatal(err)
+  }
+  if !os.SameFile(srcInfo, dstInfo) {
+    t.Errorf("dst should share src's inode, got a distinct file")
+  }
+}
+
+// TestCopyWithMode_HardlinkCrossDevice exercises copyHardlink's EXDEV
+// fallback (os.Link refuses to link across filesystems) by placing src
+// and dst on different mounts: t.TempDir (same filesystem as /tmp) and
+// the tmpfs at /dev/shm. It's skipped if /dev/shm isn't usable.
+func TestCopyWithMode_HardlinkCrossDevice(t *testing.T) {
+  shmDir, err := os.MkdirTemp("/dev/shm", "go-shutil-test")
+  if err != nil {
+    t.Skipf("/dev/shm not usable: %v", err)
+  }
+  t.Cleanup(func() { os.RemoveAll(shmDir) })
+
+  src := filepath.Join(shmDir, "src.txt")
+  mustWriteFile(t, src, "hello world")
+  if err := os.Chmod(src, 0600); err != nil {
+    t.Fatal(err)
+  }
+
+  dst := filepath.Join(t.TempDir(), "dst.txt")
+
+  if _, err := CopyWithMode(src, dst, Hardlink, true); err != nil {
+    t.Fatalf("CopyWithMode: %v", err)
+  }
+
+  srcInfo, err := os.Stat(src)
+  if err != nil {
+    t.Fatal(err)
+  }
+  dstInfo, err := os.Stat(dst)
+  if err != nil {
+    t.Fatal(err)
+  }
+  if os.SameFile(srcInfo, dstInfo) {
+    t.Fatalf("src and dst are on different filesystems, shouldn't share an inode")
+  }
+  if got := mustReadFile(t, dst); got != "hello world" {
+    t.Errorf("dst = %q, want %q", got, "hello world")
+  }
+  if dstInfo.Mode().Perm() != 0600 {
+    t.Errorf("dst mode = %v, want 0600 (CopyMode should run on the EXDEV content-copy fallback)", dstInfo.Mode().Perm())
+  }
+}
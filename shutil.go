@@ -2,9 +2,9 @@ package shutil
 
 import (
   "fmt"
-  "io"
   "os"
   "path/filepath"
+  "sync"
 )
 
 
@@ -42,12 +42,66 @@ func IsSymlink(fi os.FileInfo) bool {
 }
 
 
+// CopyFileOptions controls how CopyFileWithOptions picks among the accelerated
+// regular-file copy paths (FICLONE, copy_file_range, sendfile). The zero
+// value tries all of them before falling back to a plain io.Copy.
+type CopyFileOptions struct {
+  // DisableClone skips the FICLONE reflink fast path.
+  DisableClone bool
+  // DisableCopyFileRange skips the copy_file_range(2) fast path.
+  DisableCopyFileRange bool
+}
+
+// fastPathState remembers, across every file copied during a single
+// CopyTree/CopyTreeParallel walk, whether FICLONE and copy_file_range
+// have already proven unsupported. Without it, a tree copy onto a
+// filesystem that rejects one of them (EXDEV, ENOSYS, ...) would retry
+// and fail that same syscall again for every remaining file. A nil
+// *fastPathState (the zero value used by standalone CopyFile calls)
+// just means "nothing remembered yet, nothing to remember afterwards".
+type fastPathState struct {
+  mu                   sync.Mutex
+  disableClone         bool
+  disableCopyFileRange bool
+}
+
+func (s *fastPathState) snapshot(opts CopyFileOptions) (disableClone, disableCopyFileRange bool) {
+  disableClone, disableCopyFileRange = opts.DisableClone, opts.DisableCopyFileRange
+  if s == nil {
+    return
+  }
+  s.mu.Lock()
+  defer s.mu.Unlock()
+  return disableClone || s.disableClone, disableCopyFileRange || s.disableCopyFileRange
+}
+
+func (s *fastPathState) record(disableClone, disableCopyFileRange bool) {
+  if s == nil {
+    return
+  }
+  s.mu.Lock()
+  defer s.mu.Unlock()
+  s.disableClone = s.disableClone || disableClone
+  s.disableCopyFileRange = s.disableCopyFileRange || disableCopyFileRange
+}
+
 // Copy data from src to dst
 //
 // If followSymlinks is not set and src is a symbolic link, a
 // new symlink will be created instead of copying the file it points
 // to.
 func CopyFile(src, dst string, followSymlinks bool) (error) {
+  return CopyFileWithOptions(src, dst, followSymlinks, CopyFileOptions{})
+}
+
+// CopyFileWithOptions is CopyFile with control over which of the
+// accelerated regular-file copy paths are allowed, for callers that want
+// deterministic (if slower) behavior.
+func CopyFileWithOptions(src, dst string, followSymlinks bool, opts CopyFileOptions) error {
+  return copyFile(src, dst, followSymlinks, opts, nil)
+}
+
+func copyFile(src, dst string, followSymlinks bool, opts CopyFileOptions, state *fastPathState) error {
   if samefile(src, dst) {
     return &SameFileError{src, dst}
   }
@@ -100,7 +154,9 @@ func CopyFile(src, dst string, followSymlinks bool) (error) {
   }
   defer fdst.Close()
 
-  size, err := io.Copy(fdst, fsrc)
+  disableClone, disableCopyFileRange := state.snapshot(opts)
+  size, err := copyRegular(fdst, fsrc, srcStat.Size(), &disableClone, &disableCopyFileRange)
+  state.record(disableClone, disableCopyFileRange)
   if err != nil {
     return err
   }
@@ -152,6 +208,10 @@ func CopyMode(src, dst string, followSymlinks bool) error {
 // If source and destination are the same file, a SameFileError will be
 // rased.
 func Copy(src, dst string, followSymlinks bool) (string, error){
+  return copyWithState(src, dst, followSymlinks, nil)
+}
+
+func copyWithState(src, dst string, followSymlinks bool, state *fastPathState) (string, error) {
   dstInfo, err := os.Stat(dst)
 
   if err == nil && dstInfo.Mode().IsDir() {
@@ -162,7 +222,7 @@ func Copy(src, dst string, followSymlinks bool) (string, error){
     return dst, err
   }
 
-  err = CopyFile(src, dst, followSymlinks)
+  err = copyFile(src, dst, followSymlinks, CopyFileOptions{}, state)
   if err != nil {
     return dst, err
   }
@@ -174,3 +234,29 @@ func Copy(src, dst string, followSymlinks bool) (string, error){
 
   return dst, nil
 }
+
+
+// Copy2 is Copy plus CopyStat: data, mode bits, timestamps, ownership
+// and extended attributes ("cp -a src dst"). Return the file's
+// destination.
+//
+// A missing-privilege failure to copy a single extended attribute (see
+// XattrPermissionError) does not fail the copy.
+func Copy2(src, dst string, followSymlinks bool) (string, error) {
+  return copy2(src, dst, followSymlinks, nil)
+}
+
+func copy2(src, dst string, followSymlinks bool, state *fastPathState) (string, error) {
+  dst, err := copyWithState(src, dst, followSymlinks, state)
+  if err != nil {
+    return dst, err
+  }
+
+  if err := CopyStat(src, dst, followSymlinks); err != nil {
+    if _, ok := err.(*XattrPermissionError); !ok {
+      return dst, err
+    }
+  }
+
+  return dst, nil
+}
@@ -5,25 +5,127 @@ package shutil
 /*
 #include <sys/ioctl.h>
 
-#undef BTRFS_IOCTL_MAGIC
-#define BTRFS_IOCTL_MAGIC 0x94
-#undef BTRFS_IOC_CLONE
-#define BTRFS_IOC_CLONE _IOW (BTRFS_IOCTL_MAGIC, 9, int)
+#undef FICLONE
+#define FICLONE _IOW(0x94, 9, int)
 */
 import "C"
 
 import (
+	"io"
 	"os"
 	"syscall"
+
+	"golang.org/x/sys/unix"
 )
 
 const (
-	BtrfsIocClone = C.BTRFS_IOC_CLONE
+	// FICLONE clones the data of fsrc into fdst, copy-on-write, when both
+	// files live on the same filesystem and that filesystem supports
+	// reflinks (btrfs, XFS with reflink=1, ...). Despite the name this
+	// ioctl number was introduced as BTRFS_IOC_CLONE; every CoW-capable
+	// filesystem since has kept the same (magic, number) pair.
+	FICLONE = C.FICLONE
 )
 
-func clonefile(fdst *os.File, fsrc *os.File) (bool, error) {
-	if _, _, err := syscall.Syscall(syscall.SYS_IOCTL, fdst.Fd(), BtrfsIocClone, fsrc.Fd()); err != 0 {
+func ficlone(fdst *os.File, fsrc *os.File) (bool, error) {
+	if _, _, err := syscall.Syscall(syscall.SYS_IOCTL, fdst.Fd(), FICLONE, fsrc.Fd()); err != 0 {
 		return false, err
 	}
 	return true, nil
 }
+
+func isCloneUnsupported(err error) bool {
+	errno, ok := err.(syscall.Errno)
+	if !ok {
+		return false
+	}
+	switch errno {
+	case syscall.EXDEV, syscall.ENOTTY, syscall.EINVAL, syscall.EOPNOTSUPP:
+		return true
+	}
+	return false
+}
+
+func isCopyFileRangeUnsupported(err error) bool {
+	errno, ok := err.(unix.Errno)
+	if !ok {
+		return false
+	}
+	switch errno {
+	case unix.EXDEV, unix.ENOSYS, unix.EINVAL, unix.EOPNOTSUPP:
+		return true
+	}
+	return false
+}
+
+func copyFileRange(fdst, fsrc *os.File, size int64) (int64, error) {
+	var written int64
+	for written < size {
+		n, err := unix.CopyFileRange(int(fsrc.Fd()), nil, int(fdst.Fd()), nil, int(size-written), 0)
+		if err != nil {
+			return written, err
+		}
+		if n == 0 {
+			break
+		}
+		written += int64(n)
+	}
+	return written, nil
+}
+
+// reflink clones fsrc's data into fdst via FICLONE, the only path
+// CopyWithMode's Reflink mode is willing to take.
+func reflink(fdst, fsrc *os.File) error {
+	if ok, err := ficlone(fdst, fsrc); ok {
+		return nil
+	} else if isCloneUnsupported(err) {
+		return &ReflinkUnsupportedError{err}
+	} else {
+		return err
+	}
+}
+
+// copyRegular copies size bytes from fsrc to fdst, preferring the
+// cheapest kernel-assisted path the source and destination filesystems
+// support: FICLONE (same-filesystem CoW reflink), then
+// copy_file_range(2), then sendfile(2), falling back to a plain
+// userspace io.Copy if none of those are available.
+//
+// disableClone and disableCopyFileRange are owned by the caller and may
+// be shared across several copyRegular calls (e.g. the files of a single
+// CopyTree); once a syscall proves unsupported (EXDEV, ENOSYS, EINVAL,
+// ...) the corresponding flag is set so later calls don't pay for a
+// doomed retry.
+func copyRegular(fdst, fsrc *os.File, size int64, disableClone, disableCopyFileRange *bool) (int64, error) {
+	if !*disableClone {
+		if ok, err := ficlone(fdst, fsrc); ok {
+			return size, nil
+		} else if !isCloneUnsupported(err) {
+			return 0, err
+		}
+		*disableClone = true
+	}
+
+	if !*disableCopyFileRange {
+		written, err := copyFileRange(fdst, fsrc, size)
+		if err == nil {
+			return written, nil
+		}
+		if !isCopyFileRangeUnsupported(err) {
+			return written, err
+		}
+		*disableCopyFileRange = true
+	}
+
+	if written, err := syscall.Sendfile(int(fdst.Fd()), int(fsrc.Fd()), nil, int(size)); err == nil {
+		return int64(written), nil
+	}
+
+	if _, err := fsrc.Seek(0, io.SeekStart); err != nil {
+		return 0, err
+	}
+	if _, err := fdst.Seek(0, io.SeekStart); err != nil {
+		return 0, err
+	}
+	return io.Copy(fdst, fsrc)
+}
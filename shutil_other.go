@@ -0,0 +1,22 @@
+// +build !linux !cgo
+
+package shutil
+
+import (
+	"errors"
+	"io"
+	"os"
+)
+
+// copyRegular has no kernel-assisted fast path on this platform (or this
+// build lacks cgo), so it always takes the plain userspace io.Copy path.
+func copyRegular(fdst, fsrc *os.File, size int64, disableClone, disableCopyFileRange *bool) (int64, error) {
+	*disableClone = true
+	*disableCopyFileRange = true
+	return io.Copy(fdst, fsrc)
+}
+
+// reflink is never supported without FICLONE.
+func reflink(fdst, fsrc *os.File) error {
+	return &ReflinkUnsupportedError{errors.New("FICLONE not supported on this platform or build")}
+}
@@ -0,0 +1,25 @@
+package shutil
+
+import (
+  "path/filepath"
+  "testing"
+)
+
+// TestCopyFileWithOptions_DisableFastPaths forces CopyFileWithOptions
+// past FICLONE and copy_file_range entirely (leaving sendfile, then the
+// plain io.Copy fallback), and checks the copy is still byte-correct.
+func TestCopyFileWithOptions_DisableFastPaths(t *testing.T) {
+  root := t.TempDir()
+  src := filepath.Join(root, "src.txt")
+  dst := filepath.Join(root, "dst.txt")
+  mustWriteFile(t, src, "hello world")
+
+  opts := CopyFileOptions{DisableClone: true, DisableCopyFileRange: true}
+  if err := CopyFileWithOptions(src, dst, true, opts); err != nil {
+    t.Fatalf("CopyFileWithOptions: %v", err)
+  }
+
+  if got := mustReadFile(t, dst); got != "hello world" {
+    t.Errorf("dst = %q, want %q", got, "hello world")
+  }
+}
@@ -0,0 +1,167 @@
+// +build linux
+
+package shutil
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// XattrPermissionError is returned as CopyStat's error when setting a
+// security.* or trusted.* extended attribute on dst fails with EPERM or
+// EACCES, as is expected when not running as root. CopyStat treats it
+// as non-fatal: it goes on copying the remaining attributes rather than
+// aborting. A permission failure in any other namespace is returned
+// as-is.
+type XattrPermissionError struct {
+	Name string
+	Err  error
+}
+
+func (e *XattrPermissionError) Error() string {
+	return fmt.Sprintf("xattr %s: %s", e.Name, e.Err)
+}
+
+func (e *XattrPermissionError) Unwrap() error {
+	return e.Err
+}
+
+// CopyStat copies mtime, atime, ownership and extended attributes from
+// src to dst, Python shutil.copystat style. It does not touch
+// permission bits; use CopyMode (or Copy2, which does both) for that.
+//
+// If followSymlinks is false and src is a symlink, the symlink itself
+// (not its target) is updated. If followSymlinks is true and src is a
+// symlink, its target's metadata is used instead, matching CopyFile
+// (which copies the target's bytes in that case).
+func CopyStat(src, dst string, followSymlinks bool) error {
+	srcStat, err := os.Lstat(src)
+	if err != nil {
+		return err
+	}
+
+	statSrc := src
+	if followSymlinks && IsSymlink(srcStat) {
+		statSrc, err = filepath.EvalSymlinks(src)
+		if err != nil {
+			return err
+		}
+	}
+
+	var st unix.Stat_t
+	if err := unix.Lstat(statSrc, &st); err != nil {
+		return err
+	}
+	atime := time.Unix(st.Atim.Sec, st.Atim.Nsec)
+	mtime := time.Unix(st.Mtim.Sec, st.Mtim.Nsec)
+
+	if !followSymlinks && IsSymlink(srcStat) {
+		if err := lchtimes(dst, atime, mtime); err != nil {
+			return err
+		}
+	} else if err := os.Chtimes(dst, atime, mtime); err != nil {
+		return err
+	}
+
+	if err := os.Lchown(dst, int(st.Uid), int(st.Gid)); err != nil {
+		return err
+	}
+
+	return copyXattrs(statSrc, dst)
+}
+
+// lchtimes sets a symlink's own times (as opposed to its target's)
+// via AT_SYMLINK_NOFOLLOW, since os.Chtimes always follows symlinks.
+func lchtimes(path string, atime, mtime time.Time) error {
+	ts := []unix.Timespec{
+		unix.NsecToTimespec(atime.UnixNano()),
+		unix.NsecToTimespec(mtime.UnixNano()),
+	}
+	return unix.UtimesNanoAt(unix.AT_FDCWD, path, ts, unix.AT_SYMLINK_NOFOLLOW)
+}
+
+func copyXattrs(src, dst string) error {
+	size, err := unix.Llistxattr(src, nil)
+	if err != nil {
+		if isXattrUnsupported(err) {
+			return nil
+		}
+		return err
+	}
+	if size == 0 {
+		return nil
+	}
+
+	buf := make([]byte, size)
+	n, err := unix.Llistxattr(src, buf)
+	if err != nil {
+		return err
+	}
+
+	var skipped error
+	for _, name := range splitXattrNames(buf[:n]) {
+		if err := copyXattr(src, dst, name); err != nil {
+			if permErr, ok := err.(*XattrPermissionError); ok {
+				skipped = permErr
+				continue
+			}
+			return err
+		}
+	}
+
+	return skipped
+}
+
+func copyXattr(src, dst, name string) error {
+	size, err := unix.Lgetxattr(src, name, nil)
+	if err != nil {
+		return err
+	}
+
+	var value []byte
+	if size > 0 {
+		value = make([]byte, size)
+		if _, err := unix.Lgetxattr(src, name, value); err != nil {
+			return err
+		}
+	}
+
+	if err := unix.Lsetxattr(dst, name, value, 0); err != nil {
+		if isRestrictedXattrNamespace(name) && isPermissionError(err) {
+			return &XattrPermissionError{name, err}
+		}
+		return err
+	}
+
+	return nil
+}
+
+func splitXattrNames(buf []byte) []string {
+	var names []string
+	for _, chunk := range bytes.Split(buf, []byte{0}) {
+		if len(chunk) > 0 {
+			names = append(names, string(chunk))
+		}
+	}
+	return names
+}
+
+func isRestrictedXattrNamespace(name string) bool {
+	return strings.HasPrefix(name, "security.") || strings.HasPrefix(name, "trusted.")
+}
+
+func isPermissionError(err error) bool {
+	errno, ok := err.(unix.Errno)
+	return ok && (errno == unix.EPERM || errno == unix.EACCES)
+}
+
+func isXattrUnsupported(err error) bool {
+	errno, ok := err.(unix.Errno)
+	return ok && (errno == unix.ENOTSUP || errno == unix.EOPNOTSUPP)
+}
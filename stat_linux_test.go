@@ -0,0 +1,71 @@
+// +build linux
+
+package shutil
+
+import (
+  "os"
+  "path/filepath"
+  "syscall"
+  "testing"
+
+  "golang.org/x/sys/unix"
+)
+
+// TestCopyStat_SkipsXattrPermissionFailures recreates the one situation
+// CopyStat treats as non-fatal: a security.* xattr the caller doesn't
+// have privilege to set on dst. Tests run as root, which can normally
+// set it, so this drops the euid to "nobody" for the one CopyStat call
+// that needs to fail.
+func TestCopyStat_SkipsXattrPermissionFailures(t *testing.T) {
+  if os.Geteuid() != 0 {
+    t.Skip("needs root to set a security.* xattr on src, then drop privileges for the CopyStat call")
+  }
+
+  root := t.TempDir()
+  // t.TempDir's own parent directory is created 0700 by the testing
+  // package, which would block "nobody" from even traversing down to
+  // root; open both up so only the xattr call itself is privileged.
+  if err := os.Chmod(filepath.Dir(root), 0755); err != nil {
+    t.Fatal(err)
+  }
+  if err := os.Chmod(root, 0755); err != nil {
+    t.Fatal(err)
+  }
+  src := filepath.Join(root, "src.txt")
+  dst := filepath.Join(root, "dst.txt")
+  mustWriteFile(t, src, "hello")
+  mustWriteFile(t, dst, "")
+
+  if err := unix.Lsetxattr(src, "security.go-shutil-test", []byte("v"), 0); err != nil {
+    t.Skipf("filesystem doesn't support security.* xattrs: %v", err)
+  }
+
+  // Own both files as "nobody" so the euid-dropped CopyStat call below
+  // can still get past os.Lchown (a no-op chown to the owner's own
+  // uid/gid) and reach the xattr copy, which is what should fail.
+  const nobody = 65534
+  if err := os.Chown(src, nobody, nobody); err != nil {
+    t.Skipf("can't chown src to uid %d: %v", nobody, err)
+  }
+  if err := os.Chown(dst, nobody, nobody); err != nil {
+    t.Skipf("can't chown dst to uid %d: %v", nobody, err)
+  }
+
+  if err := syscall.Setreuid(-1, nobody); err != nil {
+    t.Skipf("can't drop privileges to uid %d: %v", nobody, err)
+  }
+  t.Cleanup(func() { syscall.Setreuid(-1, 0) })
+
+  err := CopyStat(src, dst, true)
+
+  if rerr := syscall.Setreuid(-1, 0); rerr != nil {
+    t.Fatalf("restoring euid to root: %v", rerr)
+  }
+
+  if err == nil {
+    t.Fatalf("expected a non-nil error copying an unsettable security.* xattr")
+  }
+  if _, ok := err.(*XattrPermissionError); !ok {
+    t.Fatalf("CopyStat error = %T (%v), want *XattrPermissionError", err, err)
+  }
+}
@@ -0,0 +1,52 @@
+// +build !linux
+
+package shutil
+
+import (
+	"fmt"
+	"os"
+	"time"
+)
+
+// XattrPermissionError would be returned by CopyStat when copying an
+// extended attribute needs privileges the caller doesn't have. Declared
+// here too so callers can type-switch on it portably, even though
+// CopyStat never produces one on this platform: xattrs aren't copied.
+type XattrPermissionError struct {
+	Name string
+	Err  error
+}
+
+func (e *XattrPermissionError) Error() string {
+	return fmt.Sprintf("xattr %s: %s", e.Name, e.Err)
+}
+
+func (e *XattrPermissionError) Unwrap() error {
+	return e.Err
+}
+
+// CopyStat sets dst's mtime and atime to src's mtime; src's atime is
+// not copied. Ownership and extended attributes aren't copied on this
+// platform.
+func CopyStat(src, dst string, followSymlinks bool) error {
+	if !followSymlinks {
+		if lst, err := os.Lstat(src); err == nil && IsSymlink(lst) {
+			return nil
+		}
+	}
+
+	srcStat, err := os.Stat(src)
+	if err != nil {
+		return err
+	}
+	mtime := srcStat.ModTime()
+	return os.Chtimes(dst, mtime, mtime)
+}
+
+// lchtimes would set a symlink's own times without following it. This
+// platform has no portable AT_SYMLINK_NOFOLLOW-style syscall wired up,
+// so it's a no-op rather than silently stamping the wrong file (or, for
+// a dangling symlink, failing outright).
+func lchtimes(path string, atime, mtime time.Time) error {
+	return nil
+}
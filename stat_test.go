@@ -0,0 +1,44 @@
+package shutil
+
+import (
+  "os"
+  "path/filepath"
+  "syscall"
+  "testing"
+  "time"
+)
+
+func TestCopyStat_TimesAndOwnership(t *testing.T) {
+  root := t.TempDir()
+  src := filepath.Join(root, "src.txt")
+  dst := filepath.Join(root, "dst.txt")
+  mustWriteFile(t, src, "hello")
+  mustWriteFile(t, dst, "")
+
+  mtime := time.Date(2001, 2, 3, 4, 5, 6, 0, time.UTC)
+  if err := os.Chtimes(src, mtime, mtime); err != nil {
+    t.Fatal(err)
+  }
+
+  if err := CopyStat(src, dst, true); err != nil {
+    t.Fatalf("CopyStat: %v", err)
+  }
+
+  dstInfo, err := os.Stat(dst)
+  if err != nil {
+    t.Fatal(err)
+  }
+  if !dstInfo.ModTime().Equal(mtime) {
+    t.Errorf("dst mtime = %v, want %v", dstInfo.ModTime(), mtime)
+  }
+
+  srcInfo, err := os.Stat(src)
+  if err != nil {
+    t.Fatal(err)
+  }
+  srcSys := srcInfo.Sys().(*syscall.Stat_t)
+  dstSys := dstInfo.Sys().(*syscall.Stat_t)
+  if dstSys.Uid != srcSys.Uid || dstSys.Gid != srcSys.Gid {
+    t.Errorf("dst uid/gid = %d/%d, want %d/%d", dstSys.Uid, dstSys.Gid, srcSys.Uid, srcSys.Gid)
+  }
+}